@@ -0,0 +1,92 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const containerCredentialsHost = "http://169.254.170.2"
+
+// ContainerRoleProvider fetches credentials from the ECS/Fargate style
+// container credentials endpoint, the same one consulted by aws-sdk-go's
+// defaults.RemoteCredProvider. It lets the emulator inherit whatever task
+// role the host container was started with.
+type ContainerRoleProvider struct {
+	Client *http.Client
+
+	mu         sync.Mutex
+	expiration time.Time
+}
+
+// NewContainerRoleProvider returns a provider that is only usable when
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI
+// is present in the environment.
+func NewContainerRoleProvider() *ContainerRoleProvider {
+	return &ContainerRoleProvider{Client: &http.Client{Timeout: 5 * time.Second}}
+}
+
+type containerCredentialsResponse struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (p *ContainerRoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if endpoint == "" {
+		relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+		if relative == "" {
+			return Credentials{}, fmt.Errorf("no container credentials URI configured")
+		}
+		endpoint = containerCredentialsHost + relative
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to reach container credentials endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("container credentials endpoint returned status %d", resp.StatusCode)
+	}
+
+	var parsed containerCredentialsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse container credentials response: %w", err)
+	}
+
+	p.mu.Lock()
+	p.expiration = parsed.Expiration
+	p.mu.Unlock()
+
+	return Credentials{
+		AwsKey:     parsed.AccessKeyID,
+		AwsSecret:  parsed.SecretAccessKey,
+		AwsSession: parsed.Token,
+		Expiration: parsed.Expiration,
+	}, nil
+}
+
+func (p *ContainerRoleProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expiration.IsZero() || time.Now().After(p.expiration)
+}
@@ -4,10 +4,14 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,6 +20,30 @@ const (
 	BLOCKED
 )
 
+const (
+	credentialsExpiry = 16 * time.Minute
+
+	// refreshCheckInterval controls how often the background refresher looks
+	// for credentials that are about to expire.
+	refreshCheckInterval = 1 * time.Minute
+
+	// refreshBeforeExpiry is how far ahead of Expiration the background
+	// refresher tries to replace credentials, so callers never observe a
+	// token that expired while sitting in the cache.
+	refreshBeforeExpiry = 2 * time.Minute
+
+	// hydratedToken is the token a shared credentials file is hydrated into
+	// on startup, so the usual single-identity UpdateCredentials shim keeps
+	// working once that token is the only one set.
+	hydratedToken = "default"
+
+	// assumeRoleARNEnvVar names the environment variable NewCredentialsService
+	// reads an assume-role ARN from: when set, it spins up a
+	// RotatingCredentialsProvider against it automatically. No command-line
+	// flag sets it.
+	assumeRoleARNEnvVar = "AWS_LAMBDA_RIE_ASSUME_ROLE_ARN"
+)
+
 var ErrCredentialsNotFound = fmt.Errorf("credentials not found for the provided token")
 
 type Credentials struct {
@@ -28,53 +56,309 @@ type Credentials struct {
 type CredentialsService interface {
 	SetCredentials(token, awsKey, awsSecret, awsSession string)
 	GetCredentials(token string) (*Credentials, error)
+	GetCredentialsWithContext(ctx context.Context, token string) (*Credentials, error)
 	UpdateCredentials(awsKey, awsSecret, awsSession string) error
+	UpdateCredentialsByToken(token, awsKey, awsSecret, awsSession string, expiration time.Time) error
+	ListTokens() []string
+	DeleteCredentials(token string)
 	BlockService()
 	UnblockService()
 }
 
+// serviceGate is a binary semaphore that, unlike sync.Mutex, can be acquired
+// with a context so a blocked caller can give up instead of waiting forever
+// for BlockService/UnblockService to cycle.
+type serviceGate chan struct{}
+
+func newServiceGate() serviceGate {
+	return make(serviceGate, 1)
+}
+
+func (g serviceGate) Lock() {
+	g <- struct{}{}
+}
+
+func (g serviceGate) Unlock() {
+	<-g
+}
+
+// LockContext acquires the gate, returning ctx.Err() if ctx is done first.
+func (g serviceGate) LockContext(ctx context.Context) error {
+	select {
+	case g <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 type credentialsServiceImpl struct {
 	credentials  map[string]Credentials
 	contentMutex *sync.Mutex
-	serviceMutex *sync.Mutex
+	serviceGate  serviceGate
 	currentState int
+
+	// chain is consulted whenever a requested token has no credentials of
+	// its own yet, and is walked proactively by the background refresher so
+	// long-running invokes pick up real IAM credentials instead of quietly
+	// serving stale ones past Expiration.
+	chain CredentialsProvider
+
+	// sharedFile, when configured, is hydrated from on startup and written
+	// through to on every SetCredentials/UpdateCredentials, so a developer
+	// keeps the same session across multiple `docker run` invocations of
+	// the RIE instead of re-injecting credentials every time.
+	sharedFile *SharedFileCredentialsProvider
+
+	// rotation, when configured, is given first look in the default chain
+	// so an explicitly assumed role takes priority over the environment,
+	// shared file, container role and IMDS fallbacks.
+	rotation *RotatingCredentialsProvider
+
+	// chainSet is true once WithProviderChain has run, so NewCredentialsService
+	// knows not to clobber a caller-supplied chain with the default one.
+	chainSet bool
+}
+
+// CredentialsServiceOption configures optional behavior of a
+// credentialsServiceImpl at construction time.
+type CredentialsServiceOption func(*credentialsServiceImpl)
+
+// WithProviderChain overrides the default provider chain (environment,
+// shared credentials file, container role, IMDS) that backs lookups for
+// tokens nobody has explicitly set credentials for.
+func WithProviderChain(providers ...CredentialsProvider) CredentialsServiceOption {
+	return func(c *credentialsServiceImpl) {
+		c.chain = NewChainProvider(providers...)
+		c.chainSet = true
+	}
+}
+
+// WithCredentialsFile configures the shared credentials file (the standard
+// `~/.aws/credentials` layout) NewCredentialsService hydrates from on
+// startup and persists to on every SetCredentials/UpdateCredentials. path
+// and profile fall back the same way NewSharedFileCredentialsProvider does
+// when empty, i.e. to AWS_SHARED_CREDENTIALS_FILE / ~/.aws/credentials and
+// the "default" profile.
+func WithCredentialsFile(path, profile string) CredentialsServiceOption {
+	return func(c *credentialsServiceImpl) {
+		c.sharedFile = NewSharedFileCredentialsProvider(path, profile)
+	}
 }
 
-func NewCredentialsService() CredentialsService {
+// WithAssumeRoleRotation keeps roleARN's credentials refreshed via client by
+// calling sts:AssumeRole before they expire (skew defaults to
+// defaultRotationSkew when zero), and gives them first priority in the
+// default provider chain. When this option isn't used, NewCredentialsService
+// builds the same thing automatically from the AWS_LAMBDA_RIE_ASSUME_ROLE_ARN
+// env var.
+func WithAssumeRoleRotation(client stsAssumeRoleAPI, roleARN string, skew time.Duration) CredentialsServiceOption {
+	return func(c *credentialsServiceImpl) {
+		c.rotation = NewRotatingCredentialsProvider(client, roleARN, skew)
+	}
+}
+
+func NewCredentialsService(opts ...CredentialsServiceOption) CredentialsService {
 	credentialsService := &credentialsServiceImpl{
 		credentials:  make(map[string]Credentials),
 		contentMutex: &sync.Mutex{},
-		serviceMutex: &sync.Mutex{},
+		serviceGate:  newServiceGate(),
 		currentState: UNBLOCKED,
 	}
 
+	for _, opt := range opts {
+		opt(credentialsService)
+	}
+
+	if credentialsService.sharedFile == nil {
+		if path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); path != "" {
+			credentialsService.sharedFile = NewSharedFileCredentialsProvider(path, "")
+		}
+	}
+
+	if credentialsService.rotation == nil {
+		if roleARN := os.Getenv(assumeRoleARNEnvVar); roleARN != "" {
+			client, err := defaultSTSClient()
+			if err != nil {
+				log.WithError(err).Warn("failed to build default STS client for assume-role rotation")
+			} else {
+				credentialsService.rotation = NewRotatingCredentialsProvider(client, roleARN, 0)
+			}
+		}
+	}
+
+	if !credentialsService.chainSet {
+		// Reuse the same SharedFileCredentialsProvider instance backing
+		// persistToSharedFile, so a cache-miss fallback through the chain
+		// reads the same file WithCredentialsFile configured instead of
+		// silently falling back to AWS_SHARED_CREDENTIALS_FILE/~/.aws/credentials.
+		sharedFile := credentialsService.sharedFile
+		if sharedFile == nil {
+			sharedFile = NewSharedFileCredentialsProvider("", "")
+		}
+
+		providers := make([]CredentialsProvider, 0, 5)
+		if credentialsService.rotation != nil {
+			providers = append(providers, credentialsService.rotation)
+		}
+		providers = append(providers,
+			NewEnvProvider(),
+			sharedFile,
+			NewContainerRoleProvider(),
+			NewIMDSRoleProvider(),
+		)
+
+		credentialsService.chain = NewChainProvider(providers...)
+	}
+
+	if credentialsService.sharedFile != nil {
+		if creds, err := credentialsService.sharedFile.Retrieve(context.Background()); err == nil {
+			credentialsService.credentials[hydratedToken] = creds
+			log.WithField("path", credentialsService.sharedFile.Path).Info("hydrated credentials from shared credentials file")
+		} else {
+			log.WithError(err).Debug("no shared credentials file to hydrate from")
+		}
+	}
+
+	go credentialsService.refreshExpiringCredentials()
+
 	return credentialsService
 }
 
+// defaultSTSClient builds an STS client from the ambient AWS config/env,
+// the same way the rest of the AWS SDKs discover credentials to sign with.
+func defaultSTSClient() (*sts.STS, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return sts.New(sess), nil
+}
+
+// persistToSharedFile best-effort writes creds back to the configured
+// shared credentials file, so it stays a cache rather than a hard
+// dependency: a write failure is logged, not returned to the caller. The
+// file only has one profile to write into, so persistence is skipped (and
+// logged) once more than one token is live, rather than letting one
+// identity's credentials silently clobber another's in the shared file.
+func (c *credentialsServiceImpl) persistToSharedFile(token string, creds Credentials) {
+	if c.sharedFile == nil {
+		return
+	}
+
+	if tokens := c.ListTokens(); len(tokens) > 1 {
+		log.WithField("token", token).Warn("multiple credential identities are live; skipping shared credentials file persistence to avoid clobbering another identity's credentials")
+		return
+	}
+
+	if err := c.sharedFile.Store(creds); err != nil {
+		log.WithError(err).WithField("path", c.sharedFile.Path).Warn("failed to persist credentials to shared credentials file")
+	}
+}
+
+// refreshExpiringCredentials periodically walks the chain on behalf of any
+// cached token that is within refreshBeforeExpiry of its Expiration and
+// replaces it with what the chain returns. If the chain fails, a warning is
+// logged and the cached credentials are kept and still served as they are.
+func (c *credentialsServiceImpl) refreshExpiringCredentials() {
+	ticker := time.NewTicker(refreshCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.contentMutex.Lock()
+		expiring := make([]string, 0, len(c.credentials))
+		for token, creds := range c.credentials {
+			if time.Until(creds.Expiration) <= refreshBeforeExpiry {
+				expiring = append(expiring, token)
+			}
+		}
+		c.contentMutex.Unlock()
+
+		for _, token := range expiring {
+			fresh, err := c.chain.Retrieve(context.Background())
+			if err != nil {
+				log.WithError(err).WithField("token", token).Warn("failed to proactively refresh credentials")
+				continue
+			}
+
+			c.contentMutex.Lock()
+			c.credentials[token] = fresh
+			c.contentMutex.Unlock()
+			log.WithField("token", token).Info("proactively refreshed credentials ahead of expiration")
+		}
+	}
+}
+
 func (c *credentialsServiceImpl) SetCredentials(token, awsKey, awsSecret, awsSession string) {
 	c.contentMutex.Lock()
-	defer c.contentMutex.Unlock()
-
-	c.credentials[token] = Credentials{
+	creds := Credentials{
 		AwsKey:     awsKey,
 		AwsSecret:  awsSecret,
 		AwsSession: awsSession,
-		Expiration: time.Now().Add(16 * time.Minute),
+		Expiration: time.Now().Add(credentialsExpiry),
 	}
+	c.credentials[token] = creds
+	c.contentMutex.Unlock()
+
+	c.persistToSharedFile(token, creds)
 }
 
 func (c *credentialsServiceImpl) GetCredentials(token string) (*Credentials, error) {
-	c.serviceMutex.Lock()
-	defer c.serviceMutex.Unlock()
+	return c.GetCredentialsWithContext(context.Background(), token)
+}
+
+// GetCredentialsWithContext behaves like GetCredentials but honors ctx while
+// waiting for the service gate, so a caller blocked behind BlockService (e.g.
+// during a snapshot/restore) can give up instead of hanging forever.
+//
+// Neither the service gate nor contentMutex is held across chain.Retrieve,
+// which can do real network/disk I/O (container-role HTTP, host IMDS, STS
+// AssumeRole, shared-file reads): a slow or failing cache-miss lookup for one
+// token must not stall every other token's Get/Set/Update/List/Delete call,
+// the same reasoning refreshExpiringCredentials already follows above.
+func (c *credentialsServiceImpl) GetCredentialsWithContext(ctx context.Context, token string) (*Credentials, error) {
+	credentials, ok, err := c.cachedCredentials(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &credentials, nil
+	}
+
+	if c.chain == nil {
+		return nil, ErrCredentialsNotFound
+	}
 
+	credentials, err = c.chain.Retrieve(ctx)
+	if err != nil {
+		return nil, ErrCredentialsNotFound
+	}
+
+	if err := c.serviceGate.LockContext(ctx); err != nil {
+		return nil, err
+	}
 	c.contentMutex.Lock()
-	defer c.contentMutex.Unlock()
+	c.credentials[token] = credentials
+	c.contentMutex.Unlock()
+	c.serviceGate.Unlock()
 
-	if credentials, ok := c.credentials[token]; ok {
-		return &credentials, nil
+	return &credentials, nil
+}
+
+// cachedCredentials returns any credentials already cached for token,
+// briefly holding the service gate and contentMutex rather than across the
+// chain walk GetCredentialsWithContext falls back to on a miss.
+func (c *credentialsServiceImpl) cachedCredentials(ctx context.Context, token string) (Credentials, bool, error) {
+	if err := c.serviceGate.LockContext(ctx); err != nil {
+		return Credentials{}, false, err
 	}
+	defer c.serviceGate.Unlock()
+
+	c.contentMutex.Lock()
+	defer c.contentMutex.Unlock()
 
-	return nil, ErrCredentialsNotFound
+	credentials, ok := c.credentials[token]
+	return credentials, ok, nil
 }
 
 func (c *credentialsServiceImpl) BlockService() {
@@ -82,7 +366,7 @@ func (c *credentialsServiceImpl) BlockService() {
 		return
 	}
 	log.Info("blocking the credentials service")
-	c.serviceMutex.Lock()
+	c.serviceGate.Lock()
 
 	c.contentMutex.Lock()
 	defer c.contentMutex.Unlock()
@@ -100,20 +384,61 @@ func (c *credentialsServiceImpl) UnblockService() {
 	defer c.contentMutex.Unlock()
 
 	c.currentState = UNBLOCKED
-	c.serviceMutex.Unlock()
+	c.serviceGate.Unlock()
 }
 
-func (c *credentialsServiceImpl) UpdateCredentials(awsKey, awsSecret, awsSession string) error {
-	mapSize := len(c.credentials)
-	if mapSize != 1 {
-		return fmt.Errorf("there are %d set of credentials", mapSize)
+// UpdateCredentialsByToken replaces the credentials stored for token,
+// letting the emulator host more than one function/extension identity at a
+// time instead of assuming a single set of credentials for the whole
+// service.
+func (c *credentialsServiceImpl) UpdateCredentialsByToken(token, awsKey, awsSecret, awsSession string, expiration time.Time) error {
+	c.contentMutex.Lock()
+	if _, ok := c.credentials[token]; !ok {
+		c.contentMutex.Unlock()
+		return fmt.Errorf("no credentials set for token %q", token)
 	}
 
-	var token string
-	for key := range c.credentials {
-		token = key
+	creds := Credentials{
+		AwsKey:     awsKey,
+		AwsSecret:  awsSecret,
+		AwsSession: awsSession,
+		Expiration: expiration,
 	}
+	c.credentials[token] = creds
+	c.contentMutex.Unlock()
 
-	c.SetCredentials(token, awsKey, awsSecret, awsSession)
+	c.persistToSharedFile(token, creds)
 	return nil
 }
+
+// ListTokens returns every token that currently has credentials set.
+func (c *credentialsServiceImpl) ListTokens() []string {
+	c.contentMutex.Lock()
+	defer c.contentMutex.Unlock()
+
+	tokens := make([]string, 0, len(c.credentials))
+	for token := range c.credentials {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// DeleteCredentials removes any credentials stored for token. It is a no-op
+// if token was never set.
+func (c *credentialsServiceImpl) DeleteCredentials(token string) {
+	c.contentMutex.Lock()
+	defer c.contentMutex.Unlock()
+
+	delete(c.credentials, token)
+}
+
+// UpdateCredentials is a convenience shim over UpdateCredentialsByToken for
+// the common single-identity case, preserved for existing callers.
+func (c *credentialsServiceImpl) UpdateCredentials(awsKey, awsSecret, awsSession string) error {
+	tokens := c.ListTokens()
+	if len(tokens) != 1 {
+		return fmt.Errorf("there are %d set of credentials", len(tokens))
+	}
+
+	return c.UpdateCredentialsByToken(tokens[0], awsKey, awsSecret, awsSession, time.Now().Add(credentialsExpiry))
+}
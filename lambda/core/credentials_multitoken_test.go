@@ -0,0 +1,107 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// TestMultipleIdentitiesAreIndependentlyAddressable verifies that the
+// multi-token API lets several function/extension identities coexist without
+// clobbering each other's credentials, the scenario UpdateCredentials (the
+// single-identity shim) can't support.
+func TestMultipleIdentitiesAreIndependentlyAddressable(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+
+	svc.SetCredentials("fn-a", "key-a", "secret-a", "session-a")
+	svc.SetCredentials("fn-b", "key-b", "secret-b", "session-b")
+
+	credsA, err := svc.GetCredentialsWithContext(context.Background(), "fn-a")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext(fn-a) returned error: %v", err)
+	}
+	if credsA.AwsKey != "key-a" {
+		t.Fatalf("GetCredentialsWithContext(fn-a) = %+v, want key-a's own credentials", credsA)
+	}
+
+	credsB, err := svc.GetCredentialsWithContext(context.Background(), "fn-b")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext(fn-b) returned error: %v", err)
+	}
+	if credsB.AwsKey != "key-b" {
+		t.Fatalf("GetCredentialsWithContext(fn-b) = %+v, want key-b's own credentials", credsB)
+	}
+
+	if err := svc.UpdateCredentialsByToken("fn-a", "key-a-2", "secret-a-2", "session-a-2", credsA.Expiration); err != nil {
+		t.Fatalf("UpdateCredentialsByToken(fn-a) returned error: %v", err)
+	}
+
+	updatedA, err := svc.GetCredentialsWithContext(context.Background(), "fn-a")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext(fn-a) returned error: %v", err)
+	}
+	if updatedA.AwsKey != "key-a-2" {
+		t.Fatalf("fn-a credentials = %+v, want the update to have taken effect", updatedA)
+	}
+
+	untouchedB, err := svc.GetCredentialsWithContext(context.Background(), "fn-b")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext(fn-b) returned error: %v", err)
+	}
+	if untouchedB.AwsKey != "key-b" {
+		t.Fatalf("fn-b credentials = %+v, want them unaffected by fn-a's update", untouchedB)
+	}
+}
+
+func TestDeleteCredentialsRemovesOnlyTheGivenToken(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("fn-a", "key-a", "secret-a", "")
+	svc.SetCredentials("fn-b", "key-b", "secret-b", "")
+
+	svc.DeleteCredentials("fn-a")
+
+	if _, err := svc.GetCredentialsWithContext(context.Background(), "fn-a"); err == nil {
+		t.Fatal("GetCredentialsWithContext(fn-a) = nil error, want ErrCredentialsNotFound after delete")
+	}
+	if _, err := svc.GetCredentialsWithContext(context.Background(), "fn-b"); err != nil {
+		t.Fatalf("GetCredentialsWithContext(fn-b) returned error after deleting fn-a: %v", err)
+	}
+
+	tokens := svc.ListTokens()
+	if len(tokens) != 1 || tokens[0] != "fn-b" {
+		t.Fatalf("ListTokens() = %v, want only fn-b remaining", tokens)
+	}
+}
+
+// TestSharedFilePersistenceSkippedWithMultipleIdentities guards against a
+// second identity's credentials silently overwriting the first's in the
+// shared credentials file, since the file only has one profile to persist
+// into.
+func TestSharedFilePersistenceSkippedWithMultipleIdentities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	svc := NewCredentialsService(WithProviderChain(), WithCredentialsFile(path, "default"))
+
+	svc.SetCredentials("fn-a", "key-a", "secret-a", "")
+
+	provider := NewSharedFileCredentialsProvider(path, "default")
+	persisted, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() after single SetCredentials returned error: %v", err)
+	}
+	if persisted.AwsKey != "key-a" {
+		t.Fatalf("persisted credentials = %+v, want fn-a's credentials with only one identity live", persisted)
+	}
+
+	svc.SetCredentials("fn-b", "key-b", "secret-b", "")
+
+	persisted, err = provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() after a second identity was added returned error: %v", err)
+	}
+	if persisted.AwsKey != "key-a" {
+		t.Fatalf("persisted credentials = %+v, want fn-a's credentials preserved once a second identity went live", persisted)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CredentialsProvider is implemented by anything that can produce a set of
+// AWS credentials on demand. It mirrors the shape of aws-sdk-go's
+// credentials.Provider so the chain below can host the same kind of
+// interchangeable sources (environment, shared file, container role, IMDS,
+// static) without the rest of the package caring which one is active.
+type CredentialsProvider interface {
+	// Retrieve returns a fresh set of credentials, or an error if this
+	// provider cannot currently produce any. Implementations should honor
+	// ctx cancellation for any network or disk I/O they perform.
+	Retrieve(ctx context.Context) (Credentials, error)
+
+	// IsExpired reports whether the credentials last returned by Retrieve
+	// should no longer be used. StaticProvider and
+	// SharedFileCredentialsProvider always report false; the other providers
+	// in this package report true until their first successful Retrieve.
+	IsExpired() bool
+}
+
+// ChainProvider holds a list of credential providers and satisfies requests
+// from the first one that succeeds. It remembers that provider and keeps
+// calling its Retrieve until it reports IsExpired; the credentials
+// themselves are not cached by the chain, so each Retrieve returns whatever
+// the remembered provider returns at that moment.
+// This is the same pattern as aws-sdk-go's credentials.ChainProvider.
+//
+// Retrieve is called both by callers blocked on a cache miss and by the
+// service's background refresher, concurrently and without any outside
+// locking, so curr is guarded by mu rather than assumed to be
+// single-threaded.
+type ChainProvider struct {
+	Providers []CredentialsProvider
+
+	mu   sync.Mutex
+	curr CredentialsProvider
+}
+
+// NewChainProvider builds a ChainProvider that consults providers in order.
+func NewChainProvider(providers ...CredentialsProvider) *ChainProvider {
+	return &ChainProvider{Providers: providers}
+}
+
+// Retrieve delegates to the provider that last succeeded while it does not
+// report IsExpired, otherwise it walks the chain looking for the first
+// provider that can produce fresh credentials.
+func (c *ChainProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	curr := c.currentLocked()
+	if curr != nil && !curr.IsExpired() {
+		return curr.Retrieve(ctx)
+	}
+
+	var errs []error
+	for _, p := range c.Providers {
+		creds, err := p.Retrieve(ctx)
+		if err != nil {
+			log.WithError(err).WithField("provider", fmt.Sprintf("%T", p)).Debug("credentials provider failed, trying next")
+			errs = append(errs, err)
+			continue
+		}
+
+		c.mu.Lock()
+		c.curr = p
+		c.mu.Unlock()
+		return creds, nil
+	}
+
+	return Credentials{}, fmt.Errorf("no credentials provider in the chain could supply credentials: %v", errs)
+}
+
+// IsExpired reports whether the chain needs to be walked again on the next
+// Retrieve call.
+func (c *ChainProvider) IsExpired() bool {
+	curr := c.currentLocked()
+	if curr == nil {
+		return true
+	}
+	return curr.IsExpired()
+}
+
+func (c *ChainProvider) currentLocked() CredentialsProvider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.curr
+}
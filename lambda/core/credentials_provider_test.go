@@ -0,0 +1,126 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is a minimal CredentialsProvider for exercising ChainProvider
+// without touching the network or filesystem.
+type fakeProvider struct {
+	mu      sync.Mutex
+	fail    bool
+	expired bool
+	calls   int
+}
+
+func (p *fakeProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	p.calls++
+	fail := p.fail
+	p.mu.Unlock()
+
+	if fail {
+		return Credentials{}, fmt.Errorf("fakeProvider: forced failure")
+	}
+	return Credentials{AwsKey: "key", AwsSecret: "secret", Expiration: time.Now().Add(time.Hour)}, nil
+}
+
+func (p *fakeProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expired
+}
+
+func TestChainProviderFallsThroughToFirstSuccess(t *testing.T) {
+	failing := &fakeProvider{fail: true}
+	succeeding := &fakeProvider{}
+	chain := NewChainProvider(failing, succeeding)
+
+	creds, err := chain.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() returned error: %v", err)
+	}
+	if creds.AwsKey != "key" {
+		t.Fatalf("Retrieve() = %+v, want credentials from succeeding provider", creds)
+	}
+}
+
+func TestChainProviderReturnsErrorWhenAllProvidersFail(t *testing.T) {
+	chain := NewChainProvider(&fakeProvider{fail: true}, &fakeProvider{fail: true})
+
+	if _, err := chain.Retrieve(context.Background()); err == nil {
+		t.Fatal("Retrieve() = nil error, want an error when every provider fails")
+	}
+}
+
+func TestChainProviderCachesCurrentProviderUntilExpired(t *testing.T) {
+	first := &fakeProvider{}
+	second := &fakeProvider{}
+	chain := NewChainProvider(first, second)
+
+	if _, err := chain.Retrieve(context.Background()); err != nil {
+		t.Fatalf("first Retrieve() returned error: %v", err)
+	}
+	if _, err := chain.Retrieve(context.Background()); err != nil {
+		t.Fatalf("second Retrieve() returned error: %v", err)
+	}
+
+	first.mu.Lock()
+	calls := first.calls
+	first.mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("first provider was called %d times, want 2 (cached curr re-delegates to it)", calls)
+	}
+
+	second.mu.Lock()
+	secondCalls := second.calls
+	second.mu.Unlock()
+	if secondCalls != 0 {
+		t.Fatalf("second provider was called %d times, want 0 while first is still valid", secondCalls)
+	}
+
+	first.mu.Lock()
+	first.expired = true
+	first.fail = true
+	first.mu.Unlock()
+
+	if _, err := chain.Retrieve(context.Background()); err != nil {
+		t.Fatalf("third Retrieve() returned error: %v", err)
+	}
+	second.mu.Lock()
+	secondCalls = second.calls
+	second.mu.Unlock()
+	if secondCalls != 1 {
+		t.Fatalf("second provider was called %d times after first expired, want 1", secondCalls)
+	}
+}
+
+// TestChainProviderConcurrentRetrieveIsRaceFree reproduces the pattern that
+// previously tripped `go test -race`: goroutines driving Retrieve the way
+// GetCredentialsWithContext and the background refresher do, alongside
+// goroutines calling IsExpired, all hitting curr and the leaf provider's own
+// state at the same time.
+func TestChainProviderConcurrentRetrieveIsRaceFree(t *testing.T) {
+	chain := NewChainProvider(NewEnvProvider(), &fakeProvider{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = chain.Retrieve(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			_ = chain.IsExpired()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,136 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetCredentialsWithContextReturnsSetCredentials(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("token", "key", "secret", "session")
+
+	creds, err := svc.GetCredentialsWithContext(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext() returned error: %v", err)
+	}
+	if creds.AwsKey != "key" || creds.AwsSecret != "secret" || creds.AwsSession != "session" {
+		t.Fatalf("GetCredentialsWithContext() = %+v, want the credentials set via SetCredentials", creds)
+	}
+}
+
+func TestGetCredentialsWithContextUnknownTokenWithEmptyChain(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+
+	if _, err := svc.GetCredentialsWithContext(context.Background(), "missing"); !errors.Is(err, ErrCredentialsNotFound) {
+		t.Fatalf("GetCredentialsWithContext() error = %v, want ErrCredentialsNotFound", err)
+	}
+}
+
+// TestGetCredentialsWithContextCancelledWhileBlocked verifies that a caller
+// blocked behind BlockService (e.g. during a snapshot/restore) gives up as
+// soon as its context is done instead of hanging until UnblockService.
+func TestGetCredentialsWithContextCancelledWhileBlocked(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.BlockService()
+	defer svc.UnblockService()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := svc.GetCredentialsWithContext(ctx, "token")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetCredentialsWithContext() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// TestGetCredentialsWithContextUnblocksAfterService verifies a caller that
+// started waiting behind BlockService succeeds once UnblockService runs,
+// rather than only ever being able to time out.
+func TestGetCredentialsWithContextUnblocksAfterService(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("token", "key", "secret", "session")
+	svc.BlockService()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.GetCredentialsWithContext(context.Background(), "token")
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	svc.UnblockService()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("GetCredentialsWithContext() returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetCredentialsWithContext() did not return after UnblockService")
+	}
+}
+
+func TestUpdateCredentialsByTokenRequiresExistingToken(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+
+	if err := svc.UpdateCredentialsByToken("missing", "key", "secret", "session", time.Now().Add(time.Hour)); err == nil {
+		t.Fatal("UpdateCredentialsByToken() = nil error, want an error for a token that was never set")
+	}
+}
+
+func TestUpdateCredentialsByTokenReplacesExisting(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("token", "old-key", "old-secret", "old-session")
+
+	expiration := time.Now().Add(time.Hour)
+	if err := svc.UpdateCredentialsByToken("token", "new-key", "new-secret", "new-session", expiration); err != nil {
+		t.Fatalf("UpdateCredentialsByToken() returned error: %v", err)
+	}
+
+	creds, err := svc.GetCredentialsWithContext(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("GetCredentialsWithContext() returned error: %v", err)
+	}
+	if creds.AwsKey != "new-key" || !creds.Expiration.Equal(expiration) {
+		t.Fatalf("GetCredentialsWithContext() = %+v, want updated credentials with expiration %v", creds, expiration)
+	}
+}
+
+func TestListTokensAndDeleteCredentials(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("a", "key-a", "secret-a", "")
+	svc.SetCredentials("b", "key-b", "secret-b", "")
+
+	tokens := svc.ListTokens()
+	if len(tokens) != 2 {
+		t.Fatalf("ListTokens() = %v, want 2 tokens", tokens)
+	}
+
+	svc.DeleteCredentials("a")
+	tokens = svc.ListTokens()
+	if len(tokens) != 1 || tokens[0] != "b" {
+		t.Fatalf("ListTokens() after delete = %v, want only %q", tokens, "b")
+	}
+
+	// Deleting an already-absent token is a no-op, not an error.
+	svc.DeleteCredentials("a")
+}
+
+func TestUpdateCredentialsRequiresExactlyOneToken(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+
+	if err := svc.UpdateCredentials("key", "secret", "session"); err == nil {
+		t.Fatal("UpdateCredentials() = nil error, want an error with zero tokens set")
+	}
+
+	svc.SetCredentials("a", "key-a", "secret-a", "")
+	svc.SetCredentials("b", "key-b", "secret-b", "")
+	if err := svc.UpdateCredentials("key", "secret", "session"); err == nil {
+		t.Fatal("UpdateCredentials() = nil error, want an error with more than one token set")
+	}
+}
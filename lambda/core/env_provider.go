@@ -0,0 +1,56 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// EnvProvider reads credentials from the same environment variables the AWS
+// SDKs look for, so a locally exported profile can feed the emulator without
+// any extra configuration.
+type EnvProvider struct {
+	mu        sync.Mutex
+	retrieved bool
+}
+
+// NewEnvProvider returns a provider backed by AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+func (p *EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	key := os.Getenv("AWS_ACCESS_KEY_ID")
+	secret := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	ok := key != "" && secret != ""
+
+	p.mu.Lock()
+	p.retrieved = ok
+	p.mu.Unlock()
+
+	if !ok {
+		return Credentials{}, fmt.Errorf("AWS_ACCESS_KEY_ID or AWS_SECRET_ACCESS_KEY not set in environment")
+	}
+
+	return Credentials{
+		AwsKey:     key,
+		AwsSecret:  secret,
+		AwsSession: os.Getenv("AWS_SESSION_TOKEN"),
+		// The environment doesn't carry its own TTL, so treat it like any
+		// other injected token and give it the service's usual lifetime
+		// instead of caching it with a zero Expiration forever.
+		Expiration: time.Now().Add(credentialsExpiry),
+	}, nil
+}
+
+func (p *EnvProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.retrieved
+}
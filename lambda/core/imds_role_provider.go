@@ -0,0 +1,131 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	imdsHost       = "http://169.254.169.254"
+	imdsTokenPath  = "/latest/api/token"
+	imdsRolePath   = "/latest/meta-data/iam/security-credentials/"
+	imdsTokenTTL   = "21600"
+	imdsHTTPClient = 2 * time.Second
+)
+
+// IMDSRoleProvider retrieves credentials from the real EC2 Instance Metadata
+// Service (IMDSv2) of the host the emulator happens to be running on. This
+// is distinct from the IMDS-compatible server the emulator itself exposes
+// to Lambda functions; this provider is a client of the host's IMDS.
+type IMDSRoleProvider struct {
+	Client *http.Client
+
+	mu         sync.Mutex
+	expiration time.Time
+}
+
+// NewIMDSRoleProvider returns a provider that is only usable when running
+// on an EC2 instance (or anything else fronted by an IMDSv2 endpoint).
+func NewIMDSRoleProvider() *IMDSRoleProvider {
+	return &IMDSRoleProvider{Client: &http.Client{Timeout: imdsHTTPClient}}
+}
+
+type imdsCredentialsResponse struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (p *IMDSRoleProvider) fetchToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imdsHost+imdsTokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", imdsTokenTTL)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach instance metadata service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata token request returned status %d", resp.StatusCode)
+	}
+
+	token, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+func (p *IMDSRoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	token, err := p.fetchToken(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	roleReq, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsHost+imdsRolePath, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	roleReq.Header.Set("X-aws-ec2-metadata-token", token)
+
+	roleResp, err := p.Client.Do(roleReq)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to list instance roles: %w", err)
+	}
+	role, err := io.ReadAll(roleResp.Body)
+	roleResp.Body.Close()
+	if err != nil || roleResp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("failed to discover instance role from IMDS")
+	}
+
+	credsReq, err := http.NewRequestWithContext(ctx, http.MethodGet, imdsHost+imdsRolePath+string(role), nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	credsReq.Header.Set("X-aws-ec2-metadata-token", token)
+
+	credsResp, err := p.Client.Do(credsReq)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to fetch instance role credentials: %w", err)
+	}
+	defer credsResp.Body.Close()
+
+	if credsResp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("instance role credentials request returned status %d", credsResp.StatusCode)
+	}
+
+	var parsed imdsCredentialsResponse
+	if err := json.NewDecoder(credsResp.Body).Decode(&parsed); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse instance role credentials: %w", err)
+	}
+
+	p.mu.Lock()
+	p.expiration = parsed.Expiration
+	p.mu.Unlock()
+
+	return Credentials{
+		AwsKey:     parsed.AccessKeyID,
+		AwsSecret:  parsed.SecretAccessKey,
+		AwsSession: parsed.Token,
+		Expiration: parsed.Expiration,
+	}, nil
+}
+
+func (p *IMDSRoleProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expiration.IsZero() || time.Now().After(p.expiration)
+}
@@ -0,0 +1,196 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	imdsTokenHeader    = "X-aws-ec2-metadata-token"
+	imdsTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+
+	imdsTokenPathPrefix = "/latest/api/token"
+	imdsRolePathPrefix  = "/latest/meta-data/iam/security-credentials/"
+	imdsMinTokenTTL     = 1 * time.Second
+	imdsMaxTokenTTL     = 6 * time.Hour
+	imdsDefaultTokenTTL = 6 * time.Hour
+	imdsDefaultRoleName = "lambda-rie"
+)
+
+// IMDSHandler serves the EC2 Instance Metadata Service v2 protocol on top of
+// a CredentialsService, for SDKs and tools that probe IMDS before falling
+// back to Lambda-specific credential sources. RegisterIMDSHandler mounts it
+// only when its enabled argument is true, since it otherwise shadows a real
+// IMDS endpoint some SDKs would rather talk to directly.
+type IMDSHandler struct {
+	Service  CredentialsService
+	RoleName string
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewIMDSHandler returns a handler serving roleName as the only role under
+// /latest/meta-data/iam/security-credentials/, backed by service.
+func NewIMDSHandler(service CredentialsService, roleName string) *IMDSHandler {
+	if roleName == "" {
+		roleName = imdsDefaultRoleName
+	}
+
+	return &IMDSHandler{
+		Service:  service,
+		RoleName: roleName,
+		tokens:   make(map[string]time.Time),
+	}
+}
+
+func (h *IMDSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == imdsTokenPathPrefix:
+		h.issueToken(w, r)
+	case r.Method == http.MethodGet && r.URL.Path == imdsRolePathPrefix:
+		h.requireToken(w, r, h.listRoles)
+	case r.Method == http.MethodGet && len(r.URL.Path) > len(imdsRolePathPrefix) && r.URL.Path[:len(imdsRolePathPrefix)] == imdsRolePathPrefix:
+		h.requireToken(w, r, h.roleCredentials)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *IMDSHandler) issueToken(w http.ResponseWriter, r *http.Request) {
+	ttl := imdsDefaultTokenTTL
+	if raw := r.Header.Get(imdsTokenTTLHeader); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s", imdsTokenTTLHeader), http.StatusBadRequest)
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+		if ttl < imdsMinTokenTTL || ttl > imdsMaxTokenTTL {
+			http.Error(w, fmt.Sprintf("%s must be between %d and %d", imdsTokenTTLHeader, int(imdsMinTokenTTL.Seconds()), int(imdsMaxTokenTTL.Seconds())), http.StatusBadRequest)
+			return
+		}
+	}
+
+	token, err := generateIMDSToken()
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	h.mu.Lock()
+	h.pruneExpiredLocked()
+	h.tokens[token] = time.Now().Add(ttl)
+	h.mu.Unlock()
+
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(int(ttl.Seconds())))
+	w.Write([]byte(token))
+}
+
+func (h *IMDSHandler) requireToken(w http.ResponseWriter, r *http.Request, next func(w http.ResponseWriter, r *http.Request)) {
+	token := r.Header.Get(imdsTokenHeader)
+	if token == "" {
+		http.Error(w, "missing "+imdsTokenHeader, http.StatusForbidden)
+		return
+	}
+
+	h.mu.Lock()
+	expiry, ok := h.tokens[token]
+	if ok && time.Now().After(expiry) {
+		delete(h.tokens, token)
+		ok = false
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "invalid or expired metadata token", http.StatusForbidden)
+		return
+	}
+
+	next(w, r)
+}
+
+// pruneExpiredLocked removes every token past its TTL. Callers must hold mu.
+// A client that fetches a token and never uses it would otherwise leave it
+// in the map forever, growing it unbounded over a long-running process.
+func (h *IMDSHandler) pruneExpiredLocked() {
+	now := time.Now()
+	for token, expiry := range h.tokens {
+		if now.After(expiry) {
+			delete(h.tokens, token)
+		}
+	}
+}
+
+func (h *IMDSHandler) listRoles(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(h.RoleName))
+}
+
+func (h *IMDSHandler) roleCredentials(w http.ResponseWriter, r *http.Request) {
+	role := r.URL.Path[len(imdsRolePathPrefix):]
+	if role != h.RoleName {
+		http.NotFound(w, r)
+		return
+	}
+
+	tokens := h.Service.ListTokens()
+	if len(tokens) != 1 {
+		http.Error(w, fmt.Sprintf("there are %d set of credentials, IMDS needs exactly one", len(tokens)), http.StatusNotFound)
+		return
+	}
+
+	creds, err := h.Service.GetCredentialsWithContext(r.Context(), tokens[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Code            string
+		LastUpdated     time.Time
+		Type            string
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+		Expiration      time.Time
+	}{
+		Code:            "Success",
+		LastUpdated:     time.Now(),
+		Type:            "AWS-HMAC",
+		AccessKeyId:     creds.AwsKey,
+		SecretAccessKey: creds.AwsSecret,
+		Token:           creds.AwsSession,
+		Expiration:      creds.Expiration,
+	})
+}
+
+// RegisterIMDSHandler mounts an IMDSHandler for service on mux when enabled
+// is true. It is a no-op when enabled is false. No command-line flag is
+// wired to enabled.
+func RegisterIMDSHandler(mux *http.ServeMux, service CredentialsService, roleName string, enabled bool) {
+	if !enabled {
+		return
+	}
+
+	handler := NewIMDSHandler(service, roleName)
+	mux.Handle(imdsTokenPathPrefix, handler)
+	mux.Handle(imdsRolePathPrefix, handler)
+}
+
+func generateIMDSToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
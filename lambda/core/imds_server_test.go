@@ -0,0 +1,185 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newIMDSTestHandler(t *testing.T) (*IMDSHandler, CredentialsService) {
+	t.Helper()
+	svc := NewCredentialsService(WithProviderChain())
+	svc.SetCredentials("token", "key", "secret", "session")
+	return NewIMDSHandler(svc, "test-role"), svc
+}
+
+func issueIMDSToken(t *testing.T, handler *IMDSHandler) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, imdsTokenPathPrefix, nil)
+	req.Header.Set(imdsTokenTTLHeader, "21600")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT %s status = %d, want 200; body: %s", imdsTokenPathPrefix, rec.Code, rec.Body.String())
+	}
+	return rec.Body.String()
+}
+
+func TestIMDSHandlerFullHandshake(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+	token := issueIMDSToken(t, handler)
+	if token == "" {
+		t.Fatal("issued token is empty")
+	}
+
+	rolesReq := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix, nil)
+	rolesReq.Header.Set(imdsTokenHeader, token)
+	rolesRec := httptest.NewRecorder()
+	handler.ServeHTTP(rolesRec, rolesReq)
+	if rolesRec.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, want 200", imdsRolePathPrefix, rolesRec.Code)
+	}
+	if rolesRec.Body.String() != "test-role" {
+		t.Fatalf("GET %s body = %q, want %q", imdsRolePathPrefix, rolesRec.Body.String(), "test-role")
+	}
+
+	credsReq := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix+"test-role", nil)
+	credsReq.Header.Set(imdsTokenHeader, token)
+	credsRec := httptest.NewRecorder()
+	handler.ServeHTTP(credsRec, credsReq)
+	if credsRec.Code != http.StatusOK {
+		t.Fatalf("GET %stest-role status = %d, want 200; body: %s", imdsRolePathPrefix, credsRec.Code, credsRec.Body.String())
+	}
+
+	var body struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		Token           string
+	}
+	if err := json.NewDecoder(credsRec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode credentials response: %v", err)
+	}
+	if body.AccessKeyId != "key" || body.SecretAccessKey != "secret" || body.Token != "session" {
+		t.Fatalf("credentials response = %+v, want the credentials set via SetCredentials", body)
+	}
+}
+
+func TestIMDSHandlerRejectsMissingToken(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s without token status = %d, want 403", imdsRolePathPrefix, rec.Code)
+	}
+}
+
+func TestIMDSHandlerRejectsInvalidToken(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix, nil)
+	req.Header.Set(imdsTokenHeader, "not-a-real-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s with invalid token status = %d, want 403", imdsRolePathPrefix, rec.Code)
+	}
+}
+
+func TestIMDSHandlerRejectsExpiredToken(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPut, imdsTokenPathPrefix, nil)
+	req.Header.Set(imdsTokenTTLHeader, "1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	token := rec.Body.String()
+
+	handler.mu.Lock()
+	handler.tokens[token] = time.Now().Add(-time.Second)
+	handler.mu.Unlock()
+
+	rolesReq := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix, nil)
+	rolesReq.Header.Set(imdsTokenHeader, token)
+	rolesRec := httptest.NewRecorder()
+	handler.ServeHTTP(rolesRec, rolesReq)
+
+	if rolesRec.Code != http.StatusForbidden {
+		t.Fatalf("GET %s with expired token status = %d, want 403", imdsRolePathPrefix, rolesRec.Code)
+	}
+
+	handler.mu.Lock()
+	_, stillPresent := handler.tokens[token]
+	handler.mu.Unlock()
+	if stillPresent {
+		t.Fatal("expired token is still in the map, want it pruned on lookup")
+	}
+}
+
+func TestIMDSHandlerRejectsInvalidTTL(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodPut, imdsTokenPathPrefix, nil)
+	req.Header.Set(imdsTokenTTLHeader, "99999")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("PUT %s with out-of-range TTL status = %d, want 400", imdsTokenPathPrefix, rec.Code)
+	}
+}
+
+func TestIMDSHandlerPrunesExpiredTokensOnIssue(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+
+	handler.mu.Lock()
+	handler.tokens["stale"] = time.Now().Add(-time.Minute)
+	handler.mu.Unlock()
+
+	issueIMDSToken(t, handler)
+
+	handler.mu.Lock()
+	_, stillPresent := handler.tokens["stale"]
+	handler.mu.Unlock()
+	if stillPresent {
+		t.Fatal("issuing a new token did not prune the already-expired one")
+	}
+}
+
+func TestIMDSHandlerUnknownRoleNotFound(t *testing.T) {
+	handler, _ := newIMDSTestHandler(t)
+	token := issueIMDSToken(t, handler)
+
+	req := httptest.NewRequest(http.MethodGet, imdsRolePathPrefix+"other-role", nil)
+	req.Header.Set(imdsTokenHeader, token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET %sother-role status = %d, want 404", imdsRolePathPrefix, rec.Code)
+	}
+}
+
+func TestRegisterIMDSHandlerNoopWhenDisabled(t *testing.T) {
+	svc := NewCredentialsService(WithProviderChain())
+	mux := http.NewServeMux()
+	RegisterIMDSHandler(mux, svc, "role", false)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, imdsTokenPathPrefix, nil)
+	mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("PUT %s on an unregistered mux status = %d, want 404", imdsTokenPathPrefix, rec.Code)
+	}
+}
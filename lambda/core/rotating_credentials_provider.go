@@ -0,0 +1,163 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultRotationSkew is how far ahead of Expiration a
+	// RotatingCredentialsProvider tries to refresh, mirroring
+	// refreshBeforeExpiry used by the background refresher.
+	defaultRotationSkew = 5 * time.Minute
+
+	defaultRotationBackoff    = 1 * time.Second
+	maxRotationBackoff        = 2 * time.Minute
+	rotationSessionNamePrefix = "lambda-rie"
+)
+
+// stsAssumeRoleAPI is the slice of the STS client RotatingCredentialsProvider
+// needs, so tests can supply a fake without standing up real AWS calls.
+type stsAssumeRoleAPI interface {
+	AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error)
+}
+
+// RotationStats reports the health of a RotatingCredentialsProvider's
+// background refresh loop.
+type RotationStats struct {
+	SuccessfulRefreshes int
+	FailedRefreshes     int
+	LastError           error
+	LastRefreshedAt     time.Time
+	Expiration          time.Time
+}
+
+// RotatingCredentialsProvider keeps credentials for RoleARN fresh by calling
+// sts:AssumeRole through Client before the current set expires. It does not
+// wrap another CredentialsProvider: AssumeRole is signed with whatever
+// credentials Client was built with. Concurrent Retrieve calls coalesce onto
+// a single in-flight AssumeRole call, and STS failures back off exponentially instead of
+// hammering the API.
+type RotatingCredentialsProvider struct {
+	RoleARN string
+	Client  stsAssumeRoleAPI
+	Skew    time.Duration
+
+	mu          sync.Mutex
+	current     Credentials
+	expiration  time.Time
+	backoff     time.Duration
+	nextAttempt time.Time
+	stats       RotationStats
+}
+
+// NewRotatingCredentialsProvider returns a provider that keeps roleARN's
+// credentials refreshed via client. skew defaults to defaultRotationSkew
+// when zero.
+func NewRotatingCredentialsProvider(client stsAssumeRoleAPI, roleARN string, skew time.Duration) *RotatingCredentialsProvider {
+	if skew <= 0 {
+		skew = defaultRotationSkew
+	}
+
+	return &RotatingCredentialsProvider{
+		RoleARN: roleARN,
+		Client:  client,
+		Skew:    skew,
+		backoff: defaultRotationBackoff,
+	}
+}
+
+// Retrieve returns the cached credentials, refreshing them first if they are
+// within Skew of expiring. Concurrent callers block on the same refresh and
+// share its result rather than each issuing their own AssumeRole call.
+func (p *RotatingCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.needsRefreshLocked() {
+		return p.current, nil
+	}
+
+	if time.Now().Before(p.nextAttempt) {
+		if !p.expiration.IsZero() {
+			return p.current, nil
+		}
+		return Credentials{}, fmt.Errorf("sts:AssumeRole for %s is backing off until %s: %w", p.RoleARN, p.nextAttempt, p.stats.LastError)
+	}
+
+	return p.refreshLocked(ctx)
+}
+
+func (p *RotatingCredentialsProvider) needsRefreshLocked() bool {
+	if p.expiration.IsZero() {
+		return true
+	}
+	return time.Until(p.expiration) <= p.Skew
+}
+
+func (p *RotatingCredentialsProvider) refreshLocked(ctx context.Context) (Credentials, error) {
+	sessionName := fmt.Sprintf("%s-%d", rotationSessionNamePrefix, time.Now().Unix())
+	out, err := p.Client.AssumeRoleWithContext(ctx, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(p.RoleARN),
+		RoleSessionName: aws.String(sessionName),
+	})
+	if err != nil {
+		p.stats.FailedRefreshes++
+		p.stats.LastError = err
+		p.nextAttempt = time.Now().Add(p.backoff)
+		p.backoff *= 2
+		if p.backoff > maxRotationBackoff {
+			p.backoff = maxRotationBackoff
+		}
+
+		log.WithError(err).WithField("roleArn", p.RoleARN).Warn("sts:AssumeRole failed, will retry with backoff")
+
+		if !p.expiration.IsZero() {
+			return p.current, nil
+		}
+		return Credentials{}, fmt.Errorf("failed to assume role %s: %w", p.RoleARN, err)
+	}
+
+	creds := Credentials{
+		AwsKey:     aws.StringValue(out.Credentials.AccessKeyId),
+		AwsSecret:  aws.StringValue(out.Credentials.SecretAccessKey),
+		AwsSession: aws.StringValue(out.Credentials.SessionToken),
+		Expiration: aws.TimeValue(out.Credentials.Expiration),
+	}
+
+	p.current = creds
+	p.expiration = creds.Expiration
+	p.backoff = defaultRotationBackoff
+	p.nextAttempt = time.Time{}
+	p.stats.SuccessfulRefreshes++
+	p.stats.LastError = nil
+	p.stats.LastRefreshedAt = time.Now()
+	p.stats.Expiration = creds.Expiration
+
+	return creds, nil
+}
+
+// IsExpired reports whether the cached credentials are past Skew of their
+// expiration and due for another AssumeRole call.
+func (p *RotatingCredentialsProvider) IsExpired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.needsRefreshLocked()
+}
+
+// Stats returns a snapshot of the provider's refresh history.
+func (p *RotatingCredentialsProvider) Stats() RotationStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
@@ -0,0 +1,169 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// fakeSTSClient is a minimal stsAssumeRoleAPI for driving
+// RotatingCredentialsProvider without calling real AWS.
+type fakeSTSClient struct {
+	mu        sync.Mutex
+	calls     int
+	fail      bool
+	keySuffix int
+}
+
+func (f *fakeSTSClient) AssumeRoleWithContext(ctx aws.Context, input *sts.AssumeRoleInput, opts ...request.Option) (*sts.AssumeRoleOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	if f.fail {
+		return nil, fmt.Errorf("fakeSTSClient: forced AssumeRole failure")
+	}
+
+	f.keySuffix++
+	return &sts.AssumeRoleOutput{
+		Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String(fmt.Sprintf("key-%d", f.keySuffix)),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("session"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func TestRotatingCredentialsProviderRefreshesOnFirstRetrieve(t *testing.T) {
+	client := &fakeSTSClient{}
+	p := NewRotatingCredentialsProvider(client, "arn:aws:iam::123456789012:role/test", 0)
+
+	creds, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() returned error: %v", err)
+	}
+	if creds.AwsKey != "key-1" {
+		t.Fatalf("Retrieve() = %+v, want freshly assumed credentials", creds)
+	}
+
+	client.mu.Lock()
+	calls := client.calls
+	client.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("AssumeRoleWithContext called %d times, want 1", calls)
+	}
+}
+
+func TestRotatingCredentialsProviderReusesUnexpiredCredentials(t *testing.T) {
+	client := &fakeSTSClient{}
+	p := NewRotatingCredentialsProvider(client, "arn:aws:iam::123456789012:role/test", time.Minute)
+
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatalf("first Retrieve() returned error: %v", err)
+	}
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatalf("second Retrieve() returned error: %v", err)
+	}
+
+	client.mu.Lock()
+	calls := client.calls
+	client.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("AssumeRoleWithContext called %d times, want 1 (second call should reuse cached credentials)", calls)
+	}
+}
+
+// TestRotatingCredentialsProviderConcurrentRetrieveCoalesces verifies that a
+// burst of concurrent Retrieve calls against a provider with no cached
+// credentials yet results in a single AssumeRole call, not one per caller.
+func TestRotatingCredentialsProviderConcurrentRetrieveCoalesces(t *testing.T) {
+	client := &fakeSTSClient{}
+	p := NewRotatingCredentialsProvider(client, "arn:aws:iam::123456789012:role/test", 0)
+
+	var wg sync.WaitGroup
+	var successes int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Retrieve(context.Background()); err == nil {
+				atomic.AddInt64(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 20 {
+		t.Fatalf("%d of 20 concurrent Retrieve calls succeeded, want all 20", successes)
+	}
+
+	client.mu.Lock()
+	calls := client.calls
+	client.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("AssumeRoleWithContext called %d times, want 1 (concurrent callers should coalesce)", calls)
+	}
+}
+
+func TestRotatingCredentialsProviderBacksOffOnFailureAndRecovers(t *testing.T) {
+	client := &fakeSTSClient{fail: true}
+	p := NewRotatingCredentialsProvider(client, "arn:aws:iam::123456789012:role/test", 0)
+	p.backoff = time.Millisecond
+
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Fatal("Retrieve() = nil error, want an error on first failed AssumeRole")
+	}
+
+	// A second attempt made before nextAttempt should not call STS again.
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Fatal("Retrieve() = nil error, want backoff error while within the backoff window")
+	}
+	client.mu.Lock()
+	calls := client.calls
+	client.mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("AssumeRoleWithContext called %d times while backing off, want 1", calls)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	client.mu.Lock()
+	client.fail = false
+	client.mu.Unlock()
+
+	creds, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() after backoff elapsed returned error: %v", err)
+	}
+	if creds.AwsKey == "" {
+		t.Fatalf("Retrieve() = %+v, want credentials once STS recovers", creds)
+	}
+}
+
+func TestRotatingCredentialsProviderStatsTracksRefreshes(t *testing.T) {
+	client := &fakeSTSClient{}
+	p := NewRotatingCredentialsProvider(client, "arn:aws:iam::123456789012:role/test", 0)
+
+	if _, err := p.Retrieve(context.Background()); err != nil {
+		t.Fatalf("Retrieve() returned error: %v", err)
+	}
+
+	stats := p.Stats()
+	if stats.SuccessfulRefreshes != 1 || stats.FailedRefreshes != 0 {
+		t.Fatalf("Stats() = %+v, want one successful refresh and no failures", stats)
+	}
+	if stats.Expiration.IsZero() {
+		t.Fatal("Stats().Expiration is zero, want it populated from the AssumeRole response")
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultSharedCredentialsProfile = "default"
+
+// SharedFileCredentialsProvider reads and writes credentials in the standard
+// `~/.aws/credentials` INI file, the same format every AWS SDK already
+// knows how to produce and consume. Store writes through a temp file plus
+// rename so a reader never observes a half-written file.
+type SharedFileCredentialsProvider struct {
+	Path    string
+	Profile string
+}
+
+// NewSharedFileCredentialsProvider returns a provider reading profile from
+// path. If path is empty it falls back to AWS_SHARED_CREDENTIALS_FILE and
+// then to ~/.aws/credentials. If profile is empty it defaults to "default".
+func NewSharedFileCredentialsProvider(path, profile string) *SharedFileCredentialsProvider {
+	if path == "" {
+		path = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	if profile == "" {
+		profile = defaultSharedCredentialsProfile
+	}
+
+	return &SharedFileCredentialsProvider{Path: path, Profile: profile}
+}
+
+func (p *SharedFileCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	profiles, err := parseSharedCredentialsFile(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	section, ok := profiles[p.Profile]
+	if !ok {
+		return Credentials{}, fmt.Errorf("profile %q not found in %s", p.Profile, p.Path)
+	}
+
+	if section["aws_access_key_id"] == "" || section["aws_secret_access_key"] == "" {
+		return Credentials{}, fmt.Errorf("profile %q in %s is missing required keys", p.Profile, p.Path)
+	}
+
+	creds := Credentials{
+		AwsKey:     section["aws_access_key_id"],
+		AwsSecret:  section["aws_secret_access_key"],
+		AwsSession: section["aws_session_token"],
+	}
+	if exp := section["x_expiration"]; exp != "" {
+		if t, err := time.Parse(time.RFC3339, exp); err == nil {
+			creds.Expiration = t
+		}
+	}
+
+	return creds, nil
+}
+
+func (p *SharedFileCredentialsProvider) IsExpired() bool {
+	return false
+}
+
+// Store writes creds into p.Profile, preserving every other profile already
+// in the file, and atomically replaces the file via a temp file + rename so
+// a concurrent reader never sees a partially written file.
+func (p *SharedFileCredentialsProvider) Store(creds Credentials) error {
+	if p.Path == "" {
+		return fmt.Errorf("no shared credentials file path configured")
+	}
+
+	profiles, err := loadSharedCredentialsFileOrEmpty(p.Path)
+	if err != nil {
+		return err
+	}
+
+	section, ok := profiles[p.Profile]
+	if !ok {
+		section = map[string]string{}
+		profiles[p.Profile] = section
+	}
+
+	section["aws_access_key_id"] = creds.AwsKey
+	section["aws_secret_access_key"] = creds.AwsSecret
+	section["aws_session_token"] = creds.AwsSession
+	if !creds.Expiration.IsZero() {
+		section["x_expiration"] = creds.Expiration.Format(time.RFC3339)
+	}
+
+	return writeSharedCredentialsFile(p.Path, profiles)
+}
+
+// loadSharedCredentialsFileOrEmpty behaves like parseSharedCredentialsFile
+// but treats a missing file as an empty one, since Store's first call for a
+// fresh emulator instance has nothing to merge with yet.
+func loadSharedCredentialsFileOrEmpty(path string) (map[string]map[string]string, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return map[string]map[string]string{}, nil
+	}
+	return parseSharedCredentialsFile(path)
+}
+
+// writeSharedCredentialsFile serializes profiles back to the INI format and
+// atomically replaces path with the result.
+func writeSharedCredentialsFile(path string, profiles map[string]map[string]string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create directory for shared credentials file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".credentials-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp shared credentials file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	sectionNames := make([]string, 0, len(profiles))
+	for name := range profiles {
+		sectionNames = append(sectionNames, name)
+	}
+	sort.Strings(sectionNames)
+
+	w := bufio.NewWriter(tmp)
+	for _, name := range sectionNames {
+		fmt.Fprintf(w, "[%s]\n", name)
+
+		keys := make([]string, 0, len(profiles[name]))
+		for key := range profiles[name] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s = %s\n", key, profiles[name][key])
+		}
+		fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp shared credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp shared credentials file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0o600); err != nil {
+		return fmt.Errorf("failed to set permissions on temp shared credentials file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace shared credentials file: %w", err)
+	}
+
+	return nil
+}
+
+// parseSharedCredentialsFile does a minimal INI parse: [section] headers
+// followed by key = value lines, which is all the shared credentials file
+// format requires.
+func parseSharedCredentialsFile(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shared credentials file: %w", err)
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var section string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			profiles[section] = map[string]string{}
+			continue
+		}
+
+		if section == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		profiles[section][strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
@@ -0,0 +1,100 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSharedFileCredentialsProviderStoreThenRetrieve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	p := NewSharedFileCredentialsProvider(path, "default")
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := p.Store(Credentials{
+		AwsKey:     "key",
+		AwsSecret:  "secret",
+		AwsSession: "session",
+		Expiration: expiration,
+	}); err != nil {
+		t.Fatalf("Store() returned error: %v", err)
+	}
+
+	creds, err := p.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() returned error: %v", err)
+	}
+	if creds.AwsKey != "key" || creds.AwsSecret != "secret" || creds.AwsSession != "session" {
+		t.Fatalf("Retrieve() = %+v, want the credentials just stored", creds)
+	}
+	if !creds.Expiration.Equal(expiration) {
+		t.Fatalf("Retrieve().Expiration = %v, want %v", creds.Expiration, expiration)
+	}
+}
+
+func TestSharedFileCredentialsProviderStorePreservesOtherProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	other := NewSharedFileCredentialsProvider(path, "other")
+	if err := other.Store(Credentials{AwsKey: "other-key", AwsSecret: "other-secret"}); err != nil {
+		t.Fatalf("Store() for other profile returned error: %v", err)
+	}
+
+	defaultProvider := NewSharedFileCredentialsProvider(path, "default")
+	if err := defaultProvider.Store(Credentials{AwsKey: "default-key", AwsSecret: "default-secret"}); err != nil {
+		t.Fatalf("Store() for default profile returned error: %v", err)
+	}
+
+	otherCreds, err := other.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Retrieve() for other profile returned error: %v", err)
+	}
+	if otherCreds.AwsKey != "other-key" {
+		t.Fatalf("other profile's credentials = %+v, want them to survive writing the default profile", otherCreds)
+	}
+}
+
+func TestSharedFileCredentialsProviderRetrieveMissingProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := NewSharedFileCredentialsProvider(path, "default").Store(Credentials{AwsKey: "key", AwsSecret: "secret"}); err != nil {
+		t.Fatalf("Store() returned error: %v", err)
+	}
+
+	p := NewSharedFileCredentialsProvider(path, "missing")
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Fatal("Retrieve() = nil error, want an error for a profile that was never stored")
+	}
+}
+
+func TestSharedFileCredentialsProviderRetrieveMissingFile(t *testing.T) {
+	p := NewSharedFileCredentialsProvider(filepath.Join(t.TempDir(), "does-not-exist"), "default")
+
+	if _, err := p.Retrieve(context.Background()); err == nil {
+		t.Fatal("Retrieve() = nil error, want an error when the file doesn't exist")
+	}
+}
+
+func TestSharedFileCredentialsProviderStoreIsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	p := NewSharedFileCredentialsProvider(path, "default")
+
+	if err := p.Store(Credentials{AwsKey: "key", AwsSecret: "secret"}); err != nil {
+		t.Fatalf("Store() returned error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() returned error: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "credentials" {
+			t.Fatalf("found leftover file %q in directory, want Store to clean up its temp file via rename", entry.Name())
+		}
+	}
+}
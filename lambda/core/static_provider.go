@@ -0,0 +1,36 @@
+// Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// StaticProvider returns a fixed set of credentials that were supplied
+// up front, e.g. the tokens injected via SetCredentials. It never expires
+// on its own, matching today's behavior of serving whatever was last set.
+type StaticProvider struct {
+	Value Credentials
+}
+
+// NewStaticProvider wraps creds so it can participate in a ChainProvider.
+func NewStaticProvider(creds Credentials) *StaticProvider {
+	return &StaticProvider{Value: creds}
+}
+
+func (p *StaticProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	creds := p.Value
+	if creds.Expiration.IsZero() {
+		// Give credentials that weren't constructed with their own TTL the
+		// service's usual lifetime instead of caching a zero Expiration,
+		// which SDKs treat as already expired.
+		creds.Expiration = time.Now().Add(credentialsExpiry)
+	}
+	return creds, nil
+}
+
+func (p *StaticProvider) IsExpired() bool {
+	return false
+}